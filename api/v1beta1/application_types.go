@@ -0,0 +1,299 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationSpec defines the components making up an application.
+type ApplicationSpec struct {
+	// ComponentGroupKinds is a list of Kind matchers for resources that
+	// compose this application. Every matching resource in the
+	// Application's namespace (subject to Selector) is considered a
+	// component.
+	// +optional
+	ComponentGroupKinds []metav1.GroupKind `json:"componentKinds,omitempty"`
+
+	// Selector identifies all the resources that make up the application.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// AddOwnerRef objects - flag to indicate if we want to add owner
+	// references to the components.
+	// +optional
+	AddOwnerRef bool `json:"addOwnerRef,omitempty"`
+
+	// ComponentOrder describes the order in which ComponentGroupKinds
+	// should be installed and refreshed, similar to Helm install hooks.
+	// Components in a later phase are only applied once every component
+	// matched by an earlier phase with WaitForReady set reports
+	// StatusCurrent. Phases are evaluated in slice order. A nil or empty
+	// ComponentOrder reconciles all components together, as before.
+	// +optional
+	ComponentOrder []ComponentPhase `json:"componentOrder,omitempty"`
+
+	// ComponentNamespaces lists additional namespaces, besides the
+	// Application's own, to search for matching components. Useful for a
+	// "hub" Application that aggregates components deployed across a
+	// cluster. Ignored if AllNamespaces is true.
+	// +optional
+	ComponentNamespaces []string `json:"componentNamespaces,omitempty"`
+
+	// AllNamespaces, if true, searches every namespace the controller has
+	// list/watch RBAC for instead of just the Application's own namespace
+	// (and ComponentNamespaces).
+	// +optional
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+
+	// Templates is an opt-in list of manifest templates that the
+	// controller renders and applies on every reconcile, turning the
+	// Application from a pure aggregator of pre-existing resources into a
+	// lightweight package manager for its own components. Each template is
+	// rendered with access to `.Application` (the Application object
+	// itself) and applied with server-side apply, so drift introduced
+	// between reconciles is corrected. Objects of a template removed from
+	// this list are deleted on the next reconcile (see
+	// Status.TemplatedObjects); the owner reference the controller sets on
+	// objects it renders lets Kubernetes garbage-collect them when the
+	// Application itself is deleted.
+	// +optional
+	Templates []TemplateSource `json:"templates,omitempty"`
+}
+
+// TemplateSource identifies one Go-template manifest to render and apply.
+// Exactly one of Inline, ConfigMapRef or URL should be set.
+type TemplateSource struct {
+	// Name identifies the template among its siblings; it has no bearing
+	// on the name(s) of the rendered object(s).
+	Name string `json:"name"`
+
+	// Inline is a literal Go-template manifest.
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
+	// ConfigMapRef points at a key in a ConfigMap (in the Application's
+	// namespace) holding a Go-template manifest.
+	// +optional
+	ConfigMapRef *ConfigMapTemplateSource `json:"configMapRef,omitempty"`
+
+	// URL fetches a Go-template manifest over HTTP(S). OCI artifact
+	// references are not supported yet.
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// ConfigMapTemplateSource is a reference to a template stored in a
+// ConfigMap key.
+type ConfigMapTemplateSource struct {
+	// Name of the ConfigMap, in the Application's namespace.
+	Name string `json:"name"`
+	// Key within the ConfigMap's Data holding the template.
+	Key string `json:"key"`
+}
+
+// ComponentPhase groups a subset of ComponentGroupKinds that should be
+// reconciled together, and optionally gates progression to the next phase
+// on their readiness.
+type ComponentPhase struct {
+	// Name identifies the phase for status reporting and events.
+	Name string `json:"name"`
+
+	// GroupKinds restricts this phase to components of these kinds. Each
+	// entry must also appear in Spec.ComponentGroupKinds.
+	GroupKinds []metav1.GroupKind `json:"groupKinds"`
+
+	// WaitForReady gates the start of the next phase on every component in
+	// this phase reporting StatusCurrent. When false, the next phase starts
+	// as soon as this phase's components have been applied.
+	// +optional
+	WaitForReady bool `json:"waitForReady,omitempty"`
+
+	// Timeout bounds how long the reconciler waits for this phase to
+	// become ready before surfacing a timed-out PhaseStatus. A zero
+	// Timeout means wait indefinitely, requeuing with backoff.
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ApplicationStatus defines the observed state of Application
+type ApplicationStatus struct {
+	// ObservedGeneration is the most recent generation observed by the
+	// controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ComponentList holds the status of each resource reconciled as part of
+	// this application.
+	// +optional
+	ComponentList ComponentList `json:"componentList,omitempty"`
+
+	// Conditions represents the latest available observations of the
+	// application's state.
+	// +optional
+	Conditions []ApplicationCondition `json:"conditions,omitempty"`
+
+	// PhaseStatuses reports the progress of each entry in
+	// Spec.ComponentOrder, in phase order.
+	// +optional
+	PhaseStatuses []PhaseStatus `json:"phaseStatuses,omitempty"`
+
+	// TemplatedObjects records every object currently rendered from
+	// Spec.Templates. It is compared against the previous reconcile's
+	// render to garbage-collect objects whose template was removed or
+	// renamed.
+	// +optional
+	TemplatedObjects []TemplatedObjectRef `json:"templatedObjects,omitempty"`
+}
+
+// TemplatedObjectRef identifies an object the controller rendered and
+// applied from Spec.Templates.
+type TemplatedObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// PhaseStatus reports the observed progress of a single ComponentPhase.
+type PhaseStatus struct {
+	// Name is the ComponentPhase.Name this status corresponds to.
+	Name string `json:"name"`
+
+	// State is one of Pending, InProgress, Ready or TimedOut.
+	State PhaseState `json:"state"`
+
+	// Message is a human-readable detail about State, e.g. which
+	// components are not yet ready.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time State changed.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// PhaseState is the lifecycle state of a ComponentPhase.
+type PhaseState string
+
+const (
+	// PhasePending means the phase has not started because an earlier
+	// phase is not yet ready.
+	PhasePending PhaseState = "Pending"
+	// PhaseInProgress means the phase's components have been applied and
+	// the reconciler is waiting on WaitForReady.
+	PhaseInProgress PhaseState = "InProgress"
+	// PhaseReady means every WaitForReady component in the phase is ready.
+	PhaseReady PhaseState = "Ready"
+	// PhaseTimedOut means the phase's Timeout elapsed before its
+	// components became ready.
+	PhaseTimedOut PhaseState = "TimedOut"
+)
+
+// ApplicationCondition describes the state of an application at a certain point.
+type ApplicationCondition struct {
+	// Type of condition.
+	Type ApplicationConditionType `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status metav1.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the last time the condition transitioned from
+	// one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a one-word, CamelCase reason for the condition's last
+	// transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human-readable message indicating details about the
+	// last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ApplicationConditionType is the type of an ApplicationCondition.
+type ApplicationConditionType string
+
+const (
+	// Ready means every component reports StatusCurrent.
+	Ready ApplicationConditionType = "Ready"
+	// Reconciling means at least one component is still being driven
+	// towards its desired state by its own controller (StatusInProgress
+	// or StatusTerminating), and none have failed or stalled.
+	Reconciling ApplicationConditionType = "Reconciling"
+	// Stalled means at least one component's controller reported it
+	// cannot make further progress without intervention (StatusStalled),
+	// and none have failed.
+	Stalled ApplicationConditionType = "Stalled"
+	// Error means at least one component is in StatusFailed.
+	Error ApplicationConditionType = "Error"
+)
+
+// ComponentList contains the status of the resources that make up this
+// application.
+type ComponentList struct {
+	Objects []ObjectStatus `json:"objects,omitempty"`
+}
+
+// ObjectStatus holds the status of a single component resource.
+type ObjectStatus struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Namespace is empty for cluster-scoped resources.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	Link      string `json:"link,omitempty"`
+	Status    string `json:"status,omitempty"`
+
+	// Message is a human-readable detail extracted from the resource's
+	// own status, populated whenever Status is not Current (e.g. the
+	// message of a Deployment's Progressing=False condition).
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time Status changed for this
+	// resource.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// Application is the Schema for the applications API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+type Application struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSpec   `json:"spec,omitempty"`
+	Status ApplicationStatus `json:"status,omitempty"`
+}
+
+// ApplicationList contains a list of Application
+// +kubebuilder:object:root=true
+type ApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Application `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Application{}, &ApplicationList{})
+}
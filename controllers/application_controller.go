@@ -18,18 +18,24 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	appv1beta1 "sigs.k8s.io/application/api/v1beta1"
 )
@@ -44,6 +50,28 @@ type ApplicationReconciler struct {
 	Mapper meta.RESTMapper
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// Recorder emits Kubernetes events, e.g. on ComponentOrder phase
+	// transitions. If nil, SetupWithManager populates it from
+	// mgr.GetEventRecorderFor("application-controller").
+	Recorder record.EventRecorder
+
+	// Selector restricts reconciliation to Application objects whose labels
+	// match it. It is typically populated from a command-line flag (e.g.
+	// `--application-selector=kubesphere.io/creator=`) so that multiple
+	// controllers built on sigs.k8s.io/application can partition ownership
+	// of Application objects in the same cluster instead of fighting over
+	// the same ones. A nil Selector matches every Application.
+	Selector labels.Selector
+}
+
+// matchesSelector reports whether app is within the set of Applications this
+// reconciler is responsible for, based on Selector.
+func (r *ApplicationReconciler) matchesSelector(app metav1.Object) bool {
+	if r.Selector == nil {
+		return true
+	}
+	return r.Selector.Matches(labels.Set(app.GetLabels()))
 }
 
 // +kubebuilder:rbac:groups=app.k8s.io,resources=applications,verbs=get;list;watch;create;update;patch;delete
@@ -68,21 +96,42 @@ func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, nil
 	}
 
-	resources, err := r.fetchComponentListResources(ctx, app.Spec.ComponentGroupKinds, app.Spec.Selector, app.Namespace)
+	// Defense in depth: the watch predicate installed in SetupWithManager
+	// should already keep non-matching Applications out of the work queue,
+	// but a stale informer cache or a direct enqueue could still slip one
+	// through.
+	if !r.matchesSelector(&app) {
+		return ctrl.Result{}, nil
+	}
+
+	var resources []*unstructured.Unstructured
+	var phaseStatuses []appv1beta1.PhaseStatus
+	var requeueAfter time.Duration
+	if len(app.Spec.ComponentOrder) > 0 {
+		resources, phaseStatuses, requeueAfter, err = r.reconcileOrderedComponents(ctx, &app)
+	} else {
+		resources, err = r.fetchComponentListResources(ctx, &app, app.Spec.ComponentGroupKinds, app.Spec.Selector)
+	}
+	if err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	templatedObjects, err := r.reconcileTemplates(ctx, &app)
 	if err != nil {
 		return ctrl.Result{Requeue: true}, err
 	}
 
+	ownerRef := metav1.NewControllerRef(&app, appv1beta1.GroupVersion.WithKind("Application"))
+	*ownerRef.Controller = false
 	if app.Spec.AddOwnerRef {
-		ownerRef := metav1.NewControllerRef(&app, appv1beta1.GroupVersion.WithKind("Application"))
-		*ownerRef.Controller = false
 		if err := r.setOwnerRefForResources(ctx, *ownerRef, resources); err != nil {
 			return ctrl.Result{Requeue: true}, err
 		}
+	} else if err := r.removeOwnerRefForResources(ctx, *ownerRef, resources); err != nil {
+		return ctrl.Result{Requeue: true}, err
 	}
 
-	objectStatuses := r.objectStatuses(ctx, resources)
-	aggReady := aggregateReady(objectStatuses)
+	objectStatuses := r.objectStatuses(ctx, resources, app.Status.ComponentList.Objects)
 
 	newApplicationStatus := app.Status.DeepCopy()
 
@@ -90,28 +139,47 @@ func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	newApplicationStatus.ComponentList = appv1beta1.ComponentList{
 		Objects: objectStatuses,
 	}
-
-	if aggReady {
-		setReadyCondition(newApplicationStatus, "ComponentsReady", "all components ready")
-	} else {
-		setNotReadyCondition(newApplicationStatus, "ComponentsNotReady", "some components not ready")
+	newApplicationStatus.PhaseStatuses = phaseStatuses
+	newApplicationStatus.TemplatedObjects = templatedObjects
+
+	aggregateConditions(newApplicationStatus, objectStatuses)
+
+	if !equality.Semantic.DeepEqual(newApplicationStatus, &app.Status) {
+		app.Status = *newApplicationStatus
+		// Application has the status subresource enabled, so a plain
+		// Update would silently drop everything written above: the API
+		// server ignores .status on the main endpoint once the
+		// subresource exists.
+		if err = r.Status().Update(ctx, &app); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
 	}
 
-	// TODO: Error conditions
-
-	if equality.Semantic.DeepEqual(newApplicationStatus, app.Status) {
-		return ctrl.Result{}, nil
+	if requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
-
-	app.Status = *newApplicationStatus
-	if err = r.Client.Update(ctx, &app); err != nil {
-		return ctrl.Result{Requeue: true}, err
+	if anyInProgress(objectStatuses) {
+		// Rely on the controller's exponential-backoff rate limiter rather
+		// than a watch event, since a component's own controller may not
+		// trigger one again before it converges (or gets stuck).
+		return ctrl.Result{Requeue: true}, nil
 	}
 	return ctrl.Result{}, nil
 }
 
-func (r *ApplicationReconciler) fetchComponentListResources(ctx context.Context, groupKinds []metav1.GroupKind, selector *metav1.LabelSelector, namespace string) ([]*unstructured.Unstructured, error) {
+// fetchComponentListResources lists every resource of the given groupKinds
+// that matches selector. Namespaced kinds are listed once per namespace in
+// componentNamespaces(app); cluster-scoped kinds (detected via the
+// RESTMapper) are listed without a namespace regardless of that list.
+func (r *ApplicationReconciler) fetchComponentListResources(ctx context.Context, app *appv1beta1.Application, groupKinds []metav1.GroupKind, selector *metav1.LabelSelector) ([]*unstructured.Unstructured, error) {
 	logger := getLoggerOrDie(ctx)
+
+	sel, err := labelSelectorOrEverything(selector)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := componentNamespaces(app)
+
 	var resources []*unstructured.Unstructured
 	for _, gk := range groupKinds {
 		mapping, err := r.Mapper.RESTMapping(schema.GroupKind{
@@ -123,60 +191,70 @@ func (r *ApplicationReconciler) fetchComponentListResources(ctx context.Context,
 			continue
 		}
 
-		list := &unstructured.UnstructuredList{}
-		list.SetGroupVersionKind(mapping.GroupVersionKind)
-		if err = r.Client.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels(selector.MatchLabels)); err != nil {
-			return resources, err
+		listOpts := []client.ListOption{client.MatchingLabelsSelector{Selector: sel}}
+		listNamespaces := namespaces
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			// Cluster-scoped kinds have no namespace to filter on.
+			listNamespaces = []string{""}
 		}
 
-		for _, u := range list.Items {
-			resources = append(resources, &u)
+		for _, ns := range listNamespaces {
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(mapping.GroupVersionKind)
+			opts := listOpts
+			if ns != "" {
+				opts = append(opts, client.InNamespace(ns))
+			}
+			if err = r.Client.List(ctx, list, opts...); err != nil {
+				return resources, err
+			}
+			for i := range list.Items {
+				resources = append(resources, &list.Items[i])
+			}
 		}
 	}
 	return resources, nil
 }
 
-func (r *ApplicationReconciler) setOwnerRefForResources(ctx context.Context, ownerRef metav1.OwnerReference, resources []*unstructured.Unstructured) error {
-	logger := getLoggerOrDie(ctx)
-	for _, resource := range resources {
-		ownerRefs := resource.GetOwnerReferences()
-		ownerRefFound := false
-		for i, refs := range ownerRefs {
-			if ownerRef.Kind == refs.Kind &&
-				ownerRef.APIVersion == refs.APIVersion &&
-				ownerRef.Name == refs.Name {
-				ownerRefFound = true
-				if ownerRef.UID != refs.UID {
-					ownerRefs[i] = ownerRef
-				}
-			}
-		}
+// componentNamespaces returns the namespaces fetchComponentListResources
+// should search for namespaced components, per app.Spec.AllNamespaces /
+// app.Spec.ComponentNamespaces.
+func componentNamespaces(app *appv1beta1.Application) []string {
+	if app.Spec.AllNamespaces {
+		return []string{""}
+	}
+	if len(app.Spec.ComponentNamespaces) > 0 {
+		return append([]string{app.Namespace}, app.Spec.ComponentNamespaces...)
+	}
+	return []string{app.Namespace}
+}
 
-		if !ownerRefFound {
-			ownerRefs = append(ownerRefs, ownerRef)
-		}
-		resource.SetOwnerReferences(ownerRefs)
-		err := r.Client.Update(ctx, resource)
-		if err != nil {
-			// We log this error, but we continue and try to set the ownerRefs on the other resources.
-			logger.Error(err, "ErrorSettingOwnerRef", "gvk", resource.GroupVersionKind().String(),
-				"namespace", resource.GetNamespace(), "name", resource.GetName())
-		}
+// labelSelectorOrEverything converts sel to a labels.Selector, treating a
+// nil LabelSelector as matching everything (the historical behavior of
+// client.MatchingLabels(nil)) rather than metav1.LabelSelectorAsSelector's
+// own nil handling, which matches nothing.
+func labelSelectorOrEverything(sel *metav1.LabelSelector) (labels.Selector, error) {
+	if sel == nil {
+		return labels.Everything(), nil
 	}
-	return nil
+	return metav1.LabelSelectorAsSelector(sel)
 }
 
-func (r *ApplicationReconciler) objectStatuses(ctx context.Context, resources []*unstructured.Unstructured) []appv1beta1.ObjectStatus {
+// objectStatuses computes the kstatus-style status of each resource,
+// preserving LastTransitionTime from previous for resources whose status
+// hasn't changed since the last reconcile.
+func (r *ApplicationReconciler) objectStatuses(ctx context.Context, resources []*unstructured.Unstructured, previous []appv1beta1.ObjectStatus) []appv1beta1.ObjectStatus {
 	logger := getLoggerOrDie(ctx)
 	var objectStatuses []appv1beta1.ObjectStatus
 	for _, resource := range resources {
 		os := appv1beta1.ObjectStatus{
-			Group: resource.GroupVersionKind().Group,
-			Kind:  resource.GetKind(),
-			Name:  resource.GetName(),
-			Link:  resource.GetSelfLink(),
+			Group:     resource.GroupVersionKind().Group,
+			Kind:      resource.GetKind(),
+			Name:      resource.GetName(),
+			Namespace: resource.GetNamespace(),
+			Link:      resource.GetSelfLink(),
 		}
-		s, err := status(resource)
+		s, message, err := status(resource)
 		if err != nil {
 			// Just logging the error for now. Not sure if this is the right way to handle it.
 			logger.Error(err, "unable to compute status for resource", "gvk", resource.GroupVersionKind().String(),
@@ -184,26 +262,73 @@ func (r *ApplicationReconciler) objectStatuses(ctx context.Context, resources []
 			continue
 		}
 		os.Status = s
+		os.Message = message
+
+		if prev := findObjectStatus(previous, os.Group, os.Kind, os.Namespace, os.Name); prev != nil && prev.Status == os.Status {
+			os.LastTransitionTime = prev.LastTransitionTime
+		} else {
+			os.LastTransitionTime = metav1.Now()
+		}
+
 		objectStatuses = append(objectStatuses, os)
 	}
 	return objectStatuses
 }
 
-func aggregateReady(objectStatuses []appv1beta1.ObjectStatus) bool {
-	for _, os := range objectStatuses {
-		if os.Status != StatusReady {
-			return false
+func findObjectStatus(statuses []appv1beta1.ObjectStatus, group, kind, namespace, name string) *appv1beta1.ObjectStatus {
+	for i := range statuses {
+		os := &statuses[i]
+		if os.Group == group && os.Kind == kind && os.Namespace == namespace && os.Name == name {
+			return os
 		}
 	}
-	return true
+	return nil
 }
 
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("application-controller")
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&appv1beta1.Application{}).
+		For(&appv1beta1.Application{}, builder.WithPredicates(r.selectorPredicate())).
 		Complete(r)
 }
 
+// selectorPredicate builds a predicate.Funcs that only lets events for
+// Applications matching r.Selector reach the work queue. It is the primary
+// mechanism for partitioning ownership of Application objects between
+// multiple controllers watching the same cluster; matchesSelector in
+// Reconcile is a fallback for events that predate a Selector change or come
+// from a stale cache.
+func (r *ApplicationReconciler) selectorPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return r.matchesSelector(e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return r.matchesSelector(e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return r.matchesSelector(e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return r.matchesSelector(e.Meta)
+		},
+	}
+}
+
+// ParseSelector parses a Kubernetes label selector expression (e.g.
+// "kubesphere.io/creator=" or "!some.io/owned") for use as Selector. It is a
+// thin wrapper around labels.Parse so callers (typically main.go, wiring up
+// a command-line flag) don't need to import k8s.io/apimachinery/pkg/labels
+// directly.
+func ParseSelector(expr string) (labels.Selector, error) {
+	if expr == "" {
+		return labels.Everything(), nil
+	}
+	return labels.Parse(expr)
+}
+
 func getLoggerOrDie(ctx context.Context) logr.Logger {
 	logger, ok := ctx.Value(loggerCtxKey).(logr.Logger)
 	if !ok {
@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ownerRefPatchRetries bounds how many times patchOwnerReferences re-fetches
+// and retries after losing a patch to a concurrent modification.
+const ownerRefPatchRetries = 3
+
+// setOwnerRefForResources ensures ownerRef is present on every resource,
+// patching only metadata.ownerReferences and skipping the call entirely for
+// resources that already carry an identical reference.
+func (r *ApplicationReconciler) setOwnerRefForResources(ctx context.Context, ownerRef metav1.OwnerReference, resources []*unstructured.Unstructured) error {
+	logger := getLoggerOrDie(ctx)
+	for _, resource := range resources {
+		if err := r.patchOwnerReferences(ctx, resource, func(refs []metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+			return upsertOwnerRef(refs, ownerRef)
+		}); err != nil {
+			// We log this error, but we continue and try to set the ownerRefs on the other resources.
+			logger.Error(err, "ErrorSettingOwnerRef", "gvk", resource.GroupVersionKind().String(),
+				"namespace", resource.GetNamespace(), "name", resource.GetName())
+		}
+	}
+	return nil
+}
+
+// removeOwnerRefForResources removes any reference to ownerRef's owner from
+// every resource. It is the symmetric counterpart to
+// setOwnerRefForResources, run when Spec.AddOwnerRef is toggled from true to
+// false so components don't keep a stale owner reference around.
+func (r *ApplicationReconciler) removeOwnerRefForResources(ctx context.Context, ownerRef metav1.OwnerReference, resources []*unstructured.Unstructured) error {
+	logger := getLoggerOrDie(ctx)
+	for _, resource := range resources {
+		if err := r.patchOwnerReferences(ctx, resource, func(refs []metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+			return removeOwnerRef(refs, ownerRef)
+		}); err != nil {
+			logger.Error(err, "ErrorRemovingOwnerRef", "gvk", resource.GroupVersionKind().String(),
+				"namespace", resource.GetNamespace(), "name", resource.GetName())
+		}
+	}
+	return nil
+}
+
+// patchOwnerReferences applies mutate to resource's current owner
+// references and, if it reports a change, issues a merge patch touching
+// only metadata.ownerReferences. A conflict (another actor updated the
+// resource concurrently) is handled by re-fetching the resource and
+// retrying, up to ownerRefPatchRetries times.
+func (r *ApplicationReconciler) patchOwnerReferences(ctx context.Context, resource *unstructured.Unstructured, mutate func([]metav1.OwnerReference) ([]metav1.OwnerReference, bool)) error {
+	current := resource
+	for attempt := 0; ; attempt++ {
+		newRefs, changed := mutate(current.GetOwnerReferences())
+		if !changed {
+			return nil
+		}
+
+		original := current.DeepCopy()
+		patched := current.DeepCopy()
+		patched.SetOwnerReferences(newRefs)
+
+		err := r.Client.Patch(ctx, patched, client.MergeFrom(original))
+		if err == nil {
+			return nil
+		}
+		if !errors.IsConflict(err) {
+			return err
+		}
+		if attempt >= ownerRefPatchRetries {
+			return fmt.Errorf("exceeded retry budget patching owner references on %s %s/%s: %w",
+				current.GetKind(), current.GetNamespace(), current.GetName(), err)
+		}
+
+		refreshed := &unstructured.Unstructured{}
+		refreshed.SetGroupVersionKind(current.GroupVersionKind())
+		key := client.ObjectKey{Namespace: current.GetNamespace(), Name: current.GetName()}
+		if getErr := r.Client.Get(ctx, key, refreshed); getErr != nil {
+			return getErr
+		}
+		current = refreshed
+	}
+}
+
+// upsertOwnerRef adds desired to refs, or replaces the existing reference
+// from the same owner (matched by apiVersion/kind/name) if it differs from
+// desired in any field, including UID. It reports changed=false when an
+// identical reference is already present, so callers can skip the patch
+// entirely.
+func upsertOwnerRef(refs []metav1.OwnerReference, desired metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+	for i, existing := range refs {
+		if !sameOwner(existing, desired) {
+			continue
+		}
+		if ownerRefEqual(existing, desired) {
+			return refs, false
+		}
+		out := append([]metav1.OwnerReference(nil), refs...)
+		out[i] = desired
+		return out, true
+	}
+	return append(append([]metav1.OwnerReference(nil), refs...), desired), true
+}
+
+// removeOwnerRef drops any reference to target's owner (matched by
+// apiVersion/kind/name) from refs.
+func removeOwnerRef(refs []metav1.OwnerReference, target metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+	out := make([]metav1.OwnerReference, 0, len(refs))
+	changed := false
+	for _, existing := range refs {
+		if sameOwner(existing, target) {
+			changed = true
+			continue
+		}
+		out = append(out, existing)
+	}
+	if !changed {
+		return refs, false
+	}
+	return out, true
+}
+
+// sameOwner reports whether a and b refer to the same owner object,
+// independent of UID. Kind/APIVersion/Name alone is how OLM's ownerutil
+// historically matched references, but that allows a stale UID (e.g. after
+// the owner was deleted and recreated) to look up-to-date; ownerRefEqual is
+// what actually decides whether a patch is needed.
+func sameOwner(a, b metav1.OwnerReference) bool {
+	return a.APIVersion == b.APIVersion && a.Kind == b.Kind && a.Name == b.Name
+}
+
+// ownerRefEqual reports whether a and b are the same owner reference in
+// every field relevant to reconciliation, including UID.
+func ownerRefEqual(a, b metav1.OwnerReference) bool {
+	if !sameOwner(a, b) || a.UID != b.UID {
+		return false
+	}
+	return boolPtrEqual(a.Controller, b.Controller) && boolPtrEqual(a.BlockOwnerDeletion, b.BlockOwnerDeletion)
+}
+
+func boolPtrEqual(a, b *bool) bool {
+	av := a != nil && *a
+	bv := b != nil && *b
+	return av == bv
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func ref(name string, uid types.UID, controller bool) metav1.OwnerReference {
+	c := controller
+	return metav1.OwnerReference{
+		APIVersion: "apps/v1beta1",
+		Kind:       "Application",
+		Name:       name,
+		UID:        uid,
+		Controller: &c,
+	}
+}
+
+func TestUpsertOwnerRef(t *testing.T) {
+	cases := []struct {
+		name    string
+		refs    []metav1.OwnerReference
+		desired metav1.OwnerReference
+		want    []metav1.OwnerReference
+		changed bool
+	}{
+		{
+			name:    "adds to empty list",
+			refs:    nil,
+			desired: ref("app", "uid-1", true),
+			want:    []metav1.OwnerReference{ref("app", "uid-1", true)},
+			changed: true,
+		},
+		{
+			name:    "identical reference already present is suppressed",
+			refs:    []metav1.OwnerReference{ref("app", "uid-1", true)},
+			desired: ref("app", "uid-1", true),
+			want:    []metav1.OwnerReference{ref("app", "uid-1", true)},
+			changed: false,
+		},
+		{
+			name:    "UID drift replaces the existing reference",
+			refs:    []metav1.OwnerReference{ref("app", "uid-old", true)},
+			desired: ref("app", "uid-new", true),
+			want:    []metav1.OwnerReference{ref("app", "uid-new", true)},
+			changed: true,
+		},
+		{
+			name:    "leaves unrelated owners untouched",
+			refs:    []metav1.OwnerReference{ref("other", "uid-2", true)},
+			desired: ref("app", "uid-1", true),
+			want: []metav1.OwnerReference{
+				ref("other", "uid-2", true),
+				ref("app", "uid-1", true),
+			},
+			changed: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed := upsertOwnerRef(tc.refs, tc.desired)
+			if changed != tc.changed {
+				t.Fatalf("changed = %v, want %v", changed, tc.changed)
+			}
+			if !ownerRefSlicesEqual(got, tc.want) {
+				t.Fatalf("refs = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRemoveOwnerRef(t *testing.T) {
+	cases := []struct {
+		name    string
+		refs    []metav1.OwnerReference
+		target  metav1.OwnerReference
+		want    []metav1.OwnerReference
+		changed bool
+	}{
+		{
+			name:    "removes the matching owner on toggle-off",
+			refs:    []metav1.OwnerReference{ref("app", "uid-1", true)},
+			target:  ref("app", "uid-1", true),
+			want:    []metav1.OwnerReference{},
+			changed: true,
+		},
+		{
+			name:    "leaves other owners in place",
+			refs:    []metav1.OwnerReference{ref("app", "uid-1", true), ref("other", "uid-2", true)},
+			target:  ref("app", "uid-1", true),
+			want:    []metav1.OwnerReference{ref("other", "uid-2", true)},
+			changed: true,
+		},
+		{
+			name:    "no-op when owner is absent",
+			refs:    []metav1.OwnerReference{ref("other", "uid-2", true)},
+			target:  ref("app", "uid-1", true),
+			want:    []metav1.OwnerReference{ref("other", "uid-2", true)},
+			changed: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed := removeOwnerRef(tc.refs, tc.target)
+			if changed != tc.changed {
+				t.Fatalf("changed = %v, want %v", changed, tc.changed)
+			}
+			if !ownerRefSlicesEqual(got, tc.want) {
+				t.Fatalf("refs = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOwnerRefEqual(t *testing.T) {
+	base := ref("app", "uid-1", true)
+
+	if !ownerRefEqual(base, ref("app", "uid-1", true)) {
+		t.Fatal("identical references should be equal")
+	}
+	if ownerRefEqual(base, ref("app", "uid-2", true)) {
+		t.Fatal("a UID drift should not be equal")
+	}
+	if !sameOwner(base, ref("app", "uid-2", true)) {
+		t.Fatal("sameOwner should ignore UID")
+	}
+}
+
+func TestBoolPtrEqual(t *testing.T) {
+	truthy, falsy := true, false
+	cases := []struct {
+		name string
+		a, b *bool
+		want bool
+	}{
+		{"both nil", nil, nil, true},
+		{"nil treated as false", nil, &falsy, true},
+		{"nil vs true", nil, &truthy, false},
+		{"both true", &truthy, &truthy, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := boolPtrEqual(tc.a, tc.b); got != tc.want {
+				t.Fatalf("boolPtrEqual(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+// conflictOnceClient embeds a nil client.Client so it only needs to
+// implement the methods patchOwnerReferences actually calls. The first
+// Patch fails with a conflict, as if another actor had updated the resource
+// concurrently; the subsequent Get returns a refreshed copy of the object
+// with refreshedRefs, and the retried Patch succeeds.
+type conflictOnceClient struct {
+	client.Client
+
+	patchAttempts int
+	refreshedRefs []metav1.OwnerReference
+	lastPatchRefs []metav1.OwnerReference
+}
+
+func (c *conflictOnceClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	u := obj.(*unstructured.Unstructured)
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetName(key.Name)
+	u.SetNamespace(key.Namespace)
+	u.SetOwnerReferences(c.refreshedRefs)
+	return nil
+}
+
+func (c *conflictOnceClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patchAttempts++
+	u := obj.(*unstructured.Unstructured)
+	c.lastPatchRefs = u.GetOwnerReferences()
+	if c.patchAttempts == 1 {
+		return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, u.GetName(), nil)
+	}
+	return nil
+}
+
+func TestPatchOwnerReferencesRetriesOnConflict(t *testing.T) {
+	owner := ref("app", "uid-1", true)
+	resource := &unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("ConfigMap")
+	resource.SetName("cfg")
+	resource.SetNamespace("default")
+	resource.SetOwnerReferences(nil)
+
+	fake := &conflictOnceClient{refreshedRefs: nil}
+	r := &ApplicationReconciler{Client: fake}
+
+	err := r.patchOwnerReferences(context.Background(), resource, func(refs []metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+		return upsertOwnerRef(refs, owner)
+	})
+	if err != nil {
+		t.Fatalf("patchOwnerReferences returned error after a retried conflict: %v", err)
+	}
+	if fake.patchAttempts != 2 {
+		t.Fatalf("expected one failed attempt and one successful retry, got %d attempts", fake.patchAttempts)
+	}
+	if !ownerRefSlicesEqual(fake.lastPatchRefs, []metav1.OwnerReference{owner}) {
+		t.Fatalf("retried patch carried refs %+v, want %+v", fake.lastPatchRefs, []metav1.OwnerReference{owner})
+	}
+}
+
+func TestPatchOwnerReferencesGivesUpAfterRetryBudget(t *testing.T) {
+	owner := ref("app", "uid-1", true)
+	resource := &unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("ConfigMap")
+	resource.SetName("cfg")
+	resource.SetNamespace("default")
+
+	fake := &alwaysConflictClient{}
+	r := &ApplicationReconciler{Client: fake}
+
+	err := r.patchOwnerReferences(context.Background(), resource, func(refs []metav1.OwnerReference) ([]metav1.OwnerReference, bool) {
+		return upsertOwnerRef(refs, owner)
+	})
+	if err == nil {
+		t.Fatal("expected an error once the retry budget is exceeded")
+	}
+	if fake.patchAttempts != ownerRefPatchRetries+1 {
+		t.Fatalf("expected %d patch attempts, got %d", ownerRefPatchRetries+1, fake.patchAttempts)
+	}
+}
+
+// alwaysConflictClient behaves like conflictOnceClient but never succeeds,
+// exercising patchOwnerReferences' bounded retry budget.
+type alwaysConflictClient struct {
+	client.Client
+
+	patchAttempts int
+}
+
+func (c *alwaysConflictClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	u := obj.(*unstructured.Unstructured)
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetName(key.Name)
+	u.SetNamespace(key.Namespace)
+	return nil
+}
+
+func (c *alwaysConflictClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patchAttempts++
+	u := obj.(*unstructured.Unstructured)
+	return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, u.GetName(), nil)
+}
+
+// ownerRefSlicesEqual compares two owner reference slices ignoring order,
+// since upsertOwnerRef/removeOwnerRef make no ordering guarantee beyond
+// "desired is appended last".
+func ownerRefSlicesEqual(a, b []metav1.OwnerReference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	used := make([]bool, len(b))
+	for _, ra := range a {
+		found := false
+		for i, rb := range b {
+			if used[i] {
+				continue
+			}
+			if ownerRefEqual(ra, rb) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1beta1 "sigs.k8s.io/application/api/v1beta1"
+)
+
+// reconcileOrderedComponents fetches and gates components phase-by-phase as
+// described by app.Spec.ComponentOrder. It returns every resource fetched so
+// far (across all phases that have started), the resulting per-phase
+// statuses (phases after the first unready one are reported as Pending),
+// the shortest requeue interval requested by an in-progress phase
+// (zero if none), and an error from fetching resources.
+//
+// Phases are processed in order; a phase with WaitForReady stops the walk
+// until its components report StatusCurrent, so later phases are neither
+// fetched nor applied until earlier ones are healthy.
+func (r *ApplicationReconciler) reconcileOrderedComponents(ctx context.Context, app *appv1beta1.Application) ([]*unstructured.Unstructured, []appv1beta1.PhaseStatus, time.Duration, error) {
+	var allResources []*unstructured.Unstructured
+	var phaseStatuses []appv1beta1.PhaseStatus
+	var requeueAfter time.Duration
+
+	for i, phase := range app.Spec.ComponentOrder {
+		resources, err := r.fetchComponentListResources(ctx, app, phase.GroupKinds, app.Spec.Selector)
+		if err != nil {
+			return allResources, phaseStatuses, 0, err
+		}
+		allResources = append(allResources, resources...)
+
+		ps := r.computePhaseStatus(ctx, app, phase, resources)
+		r.recordPhaseTransition(app, phase, ps)
+		phaseStatuses = append(phaseStatuses, ps)
+
+		if ps.State == appv1beta1.PhaseReady {
+			continue
+		}
+
+		// The phase is InProgress or TimedOut: don't fetch or apply later
+		// phases until it clears, but keep polling this one.
+		if requeueAfter == 0 || phaseReconcileInterval < requeueAfter {
+			requeueAfter = phaseReconcileInterval
+		}
+		// Later phases have not started; report them as Pending.
+		for _, later := range app.Spec.ComponentOrder[i+1:] {
+			pending := appv1beta1.PhaseStatus{Name: later.Name, State: appv1beta1.PhasePending, LastTransitionTime: metav1.Now()}
+			if prev := findPhaseStatus(app.Status.PhaseStatuses, later.Name); prev != nil && prev.State == appv1beta1.PhasePending {
+				pending.LastTransitionTime = prev.LastTransitionTime
+			}
+			r.recordPhaseTransition(app, later, pending)
+			phaseStatuses = append(phaseStatuses, pending)
+		}
+		break
+	}
+
+	return allResources, phaseStatuses, requeueAfter, nil
+}
+
+// phaseReconcileInterval is the backoff used to poll an in-progress phase
+// that isn't being driven by a watch event on its own components (e.g. a
+// status subresource update that doesn't touch the Application).
+const phaseReconcileInterval = 10 * time.Second
+
+// computePhaseStatus evaluates phase's readiness gate against resources,
+// preserving LastTransitionTime from the previous observed status for this
+// phase and flipping to TimedOut once phase.Timeout has elapsed while
+// InProgress.
+//
+// TimedOut is sticky: once set, it is held as-is (no new LastTransitionTime,
+// no event, no status write) until the phase actually becomes ready. Without
+// this, recomputing State as InProgress on the very next reconcile would
+// reset the timer and the phase would oscillate InProgress -> TimedOut ->
+// InProgress forever, churning events and status updates every
+// phaseReconcileInterval.
+func (r *ApplicationReconciler) computePhaseStatus(ctx context.Context, app *appv1beta1.Application, phase appv1beta1.ComponentPhase, resources []*unstructured.Unstructured) appv1beta1.PhaseStatus {
+	previous := findPhaseStatus(app.Status.PhaseStatuses, phase.Name)
+	ready := !phase.WaitForReady || allCurrent(r.objectStatuses(ctx, resources, nil))
+
+	if !ready && previous != nil && previous.State == appv1beta1.PhaseTimedOut {
+		return *previous
+	}
+
+	ps := appv1beta1.PhaseStatus{Name: phase.Name}
+	if ready {
+		ps.State = appv1beta1.PhaseReady
+	} else {
+		ps.State = appv1beta1.PhaseInProgress
+		ps.Message = fmt.Sprintf("waiting for components in phase %q to report %s", phase.Name, StatusCurrent)
+	}
+
+	if previous != nil && previous.State == ps.State {
+		ps.LastTransitionTime = previous.LastTransitionTime
+	} else {
+		ps.LastTransitionTime = metav1.Now()
+	}
+
+	if ps.State == appv1beta1.PhaseInProgress && phase.Timeout.Duration > 0 &&
+		previous != nil && previous.State == appv1beta1.PhaseInProgress &&
+		metav1.Now().Sub(previous.LastTransitionTime.Time) > phase.Timeout.Duration {
+		ps.State = appv1beta1.PhaseTimedOut
+		ps.Message = fmt.Sprintf("phase %q did not become ready within %s", phase.Name, phase.Timeout.Duration)
+		ps.LastTransitionTime = metav1.Now()
+	}
+
+	return ps
+}
+
+func findPhaseStatus(statuses []appv1beta1.PhaseStatus, name string) *appv1beta1.PhaseStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// recordPhaseTransition emits a Kubernetes event when a phase's state
+// changes, so users can watch `kubectl describe application` to follow a
+// staged rollout.
+func (r *ApplicationReconciler) recordPhaseTransition(app *appv1beta1.Application, phase appv1beta1.ComponentPhase, ps appv1beta1.PhaseStatus) {
+	if r.Recorder == nil {
+		return
+	}
+	previous := findPhaseStatus(app.Status.PhaseStatuses, phase.Name)
+	if previous != nil && previous.State == ps.State {
+		return
+	}
+
+	eventType := corev1.EventTypeNormal
+	if ps.State == appv1beta1.PhaseTimedOut {
+		eventType = corev1.EventTypeWarning
+	}
+	r.Recorder.Eventf(app, eventType, "PhaseTransition", "phase %q is now %s", phase.Name, ps.State)
+}
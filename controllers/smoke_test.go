@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1beta1 "sigs.k8s.io/application/api/v1beta1"
+)
+
+// nullLogger discards everything; it exists so pure reconciler helpers that
+// call getLoggerOrDie can be exercised directly in tests without standing up
+// a real manager.
+type nullLogger struct{}
+
+func (nullLogger) Enabled() bool                                             { return false }
+func (nullLogger) Info(msg string, keysAndValues ...interface{})             {}
+func (nullLogger) Error(err error, msg string, keysAndValues ...interface{}) {}
+func (l nullLogger) V(level int) logr.InfoLogger                             { return l }
+func (l nullLogger) WithValues(keysAndValues ...interface{}) logr.Logger     { return l }
+func (l nullLogger) WithName(name string) logr.Logger                        { return l }
+
+func testContext() context.Context {
+	return context.WithValue(context.Background(), loggerCtxKey, logr.Logger(nullLogger{}))
+}
+
+func unstructuredWithCondition(conditionType, status string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetName("web")
+	_ = unstructured.SetNestedSlice(u.Object, []interface{}{
+		map[string]interface{}{"type": conditionType, "status": status},
+	}, "status", "conditions")
+	return u
+}
+
+// TestStatusSmoke exercises the main branches of status(): Current via a
+// True Ready condition, and Stalled via the Deployment-standard
+// ProgressDeadlineExceeded signal.
+func TestStatusSmoke(t *testing.T) {
+	ready := unstructuredWithCondition("Ready", "True")
+	s, _, err := status(ready)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s != StatusCurrent {
+		t.Fatalf("status = %s, want %s", s, StatusCurrent)
+	}
+
+	stalledDeployment := &unstructured.Unstructured{}
+	stalledDeployment.SetAPIVersion("apps/v1")
+	stalledDeployment.SetKind("Deployment")
+	stalledDeployment.SetName("web")
+	_ = unstructured.SetNestedSlice(stalledDeployment.Object, []interface{}{
+		map[string]interface{}{
+			"type":   "Progressing",
+			"status": "False",
+			"reason": "ProgressDeadlineExceeded",
+		},
+	}, "status", "conditions")
+	s, _, err = status(stalledDeployment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s != StatusStalled {
+		t.Fatalf("status = %s, want %s", s, StatusStalled)
+	}
+}
+
+// TestAggregateConditionsSmoke checks that a failed component sets Error but
+// not Stalled, and a stalled component sets Stalled but not Error.
+func TestAggregateConditionsSmoke(t *testing.T) {
+	var status appv1beta1.ApplicationStatus
+	aggregateConditions(&status, []appv1beta1.ObjectStatus{{Name: "web", Status: StatusFailed}})
+	if condStatus(status, appv1beta1.Error) != metav1.ConditionTrue {
+		t.Fatal("expected Error=True when a component failed")
+	}
+	if condStatus(status, appv1beta1.Stalled) != metav1.ConditionFalse {
+		t.Fatal("expected Stalled=False when a component failed, not stalled")
+	}
+
+	status = appv1beta1.ApplicationStatus{}
+	aggregateConditions(&status, []appv1beta1.ObjectStatus{{Name: "web", Status: StatusStalled}})
+	if condStatus(status, appv1beta1.Stalled) != metav1.ConditionTrue {
+		t.Fatal("expected Stalled=True when a component is stalled")
+	}
+	if condStatus(status, appv1beta1.Error) != metav1.ConditionFalse {
+		t.Fatal("expected Error=False when a component is stalled, not failed")
+	}
+}
+
+func condStatus(status appv1beta1.ApplicationStatus, t appv1beta1.ApplicationConditionType) metav1.ConditionStatus {
+	for _, c := range status.Conditions {
+		if c.Type == t {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+// TestComputePhaseStatusTimedOutIsSticky is a smoke test for the fix that
+// makes PhaseTimedOut sticky: once a phase has timed out, recomputing its
+// status while it's still not ready must return the same TimedOut status
+// unchanged, not flip back to InProgress.
+func TestComputePhaseStatusTimedOutIsSticky(t *testing.T) {
+	r := &ApplicationReconciler{}
+	app := &appv1beta1.Application{}
+	phase := appv1beta1.ComponentPhase{Name: "phase-1", WaitForReady: true}
+
+	timedOut := appv1beta1.PhaseStatus{
+		Name:               "phase-1",
+		State:              appv1beta1.PhaseTimedOut,
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	app.Status.PhaseStatuses = []appv1beta1.PhaseStatus{timedOut}
+
+	notReady := unstructuredWithCondition("Ready", "False")
+	ps := r.computePhaseStatus(testContext(), app, phase, []*unstructured.Unstructured{notReady})
+	if ps.State != appv1beta1.PhaseTimedOut {
+		t.Fatalf("state = %s, want sticky %s", ps.State, appv1beta1.PhaseTimedOut)
+	}
+	if !ps.LastTransitionTime.Equal(&timedOut.LastTransitionTime) {
+		t.Fatal("expected LastTransitionTime to be preserved, not reset, while sticky")
+	}
+}
+
+// TestRenderTemplateInline is a smoke test for the template rendering path
+// that doesn't require a fake client or network access: an Inline source is
+// rendered with .Application bound and decoded as an unstructured object.
+func TestRenderTemplateInline(t *testing.T) {
+	r := &ApplicationReconciler{}
+	app := &appv1beta1.Application{}
+	app.Name = "my-app"
+
+	tmpl := appv1beta1.TemplateSource{
+		Name:   "cm",
+		Inline: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Application.Name }}-config\n",
+	}
+
+	obj, err := r.renderTemplate(testContext(), app, tmpl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if obj.GetKind() != "ConfigMap" {
+		t.Fatalf("kind = %s, want ConfigMap", obj.GetKind())
+	}
+	if obj.GetName() != "my-app-config" {
+		t.Fatalf("name = %s, want my-app-config", obj.GetName())
+	}
+}
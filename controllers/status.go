@@ -0,0 +1,225 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1beta1 "sigs.k8s.io/application/api/v1beta1"
+)
+
+// Component status strings, modeled on cli-utils' kstatus: every component
+// resource is classified into exactly one of these, based on its
+// .metadata.deletionTimestamp, .status.observedGeneration and
+// .status.conditions.
+const (
+	// StatusCurrent means the resource has reached its desired state.
+	StatusCurrent = "Current"
+	// StatusInProgress means the resource's controller is still working
+	// towards the desired state (e.g. a Deployment rolling out).
+	StatusInProgress = "InProgress"
+	// StatusFailed means the resource's controller reported it cannot
+	// reach the desired state without intervention.
+	StatusFailed = "Failed"
+	// StatusTerminating means the resource has a deletionTimestamp and is
+	// being garbage-collected.
+	StatusTerminating = "Terminating"
+	// StatusNotFound is reserved for a component declared by the
+	// Application but absent from the cluster; fetchComponentListResources
+	// never returns a resource we couldn't find, so this controller does
+	// not currently produce it.
+	StatusNotFound = "NotFound"
+	// StatusStalled means the resource's own controller reported it is
+	// stuck and further reconciliation will not help without
+	// intervention — distinct from StatusFailed, which is an explicit
+	// failure report. Detected from a "Stalled" condition, or from the
+	// well-known Deployment/ReplicaSet signal of a "Progressing"
+	// condition with reason "ProgressDeadlineExceeded".
+	StatusStalled = "Stalled"
+)
+
+// status computes a kstatus-style status and human-readable message for
+// resource by looking at its deletionTimestamp, .status.observedGeneration
+// and .status.conditions.
+func status(resource *unstructured.Unstructured) (status string, message string, err error) {
+	if resource.GetDeletionTimestamp() != nil {
+		return StatusTerminating, "resource is being deleted", nil
+	}
+
+	if observed, found, ferr := unstructured.NestedInt64(resource.Object, "status", "observedGeneration"); ferr == nil && found {
+		if observed < resource.GetGeneration() {
+			return StatusInProgress, "waiting for the controller to observe the latest generation", nil
+		}
+	}
+
+	conditions, found, ferr := unstructured.NestedSlice(resource.Object, "status", "conditions")
+	if ferr != nil {
+		return "", "", ferr
+	}
+	if !found {
+		// Resources with no status.conditions (ConfigMap, Secret, ...) are
+		// considered Current as soon as they exist.
+		return StatusCurrent, "", nil
+	}
+
+	var readyCondition, progressingCondition map[string]interface{}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch condition["type"] {
+		case "Failed":
+			if condition["status"] == "True" {
+				return StatusFailed, conditionMessage(condition), nil
+			}
+		case "Stalled":
+			if condition["status"] == "True" {
+				return StatusStalled, conditionMessage(condition), nil
+			}
+		case "Ready", "Available":
+			readyCondition = condition
+		case "Progressing":
+			progressingCondition = condition
+		}
+	}
+
+	// The well-known Deployment/ReplicaSet signal for "stuck, won't
+	// recover on its own": a False Progressing condition whose reason is
+	// ProgressDeadlineExceeded.
+	if progressingCondition != nil && progressingCondition["status"] == "False" &&
+		progressingCondition["reason"] == "ProgressDeadlineExceeded" {
+		return StatusStalled, conditionMessage(progressingCondition), nil
+	}
+
+	if readyCondition != nil {
+		if readyCondition["status"] == "True" {
+			return StatusCurrent, "", nil
+		}
+		return StatusInProgress, conditionMessage(readyCondition), nil
+	}
+	if progressingCondition != nil && progressingCondition["status"] == "True" {
+		return StatusInProgress, conditionMessage(progressingCondition), nil
+	}
+
+	return StatusCurrent, "", nil
+}
+
+func conditionMessage(condition map[string]interface{}) string {
+	if msg, ok := condition["message"].(string); ok && msg != "" {
+		return msg
+	}
+	if reason, ok := condition["reason"].(string); ok {
+		return reason
+	}
+	return ""
+}
+
+// allCurrent reports whether every component in objectStatuses has reached
+// StatusCurrent. It replaces the boolean "ready" notion the controller used
+// before kstatus-style classification, and is what gates
+// Spec.ComponentOrder phases with WaitForReady.
+func allCurrent(objectStatuses []appv1beta1.ObjectStatus) bool {
+	for _, os := range objectStatuses {
+		if os.Status != StatusCurrent {
+			return false
+		}
+	}
+	return true
+}
+
+// anyInProgress reports whether any component is still being reconciled by
+// its own controller, so the caller can requeue instead of relying solely
+// on watch events, which may never fire again once the component's status
+// stabilizes short of Current.
+func anyInProgress(objectStatuses []appv1beta1.ObjectStatus) bool {
+	for _, os := range objectStatuses {
+		if os.Status == StatusInProgress || os.Status == StatusTerminating {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateConditions derives the application-level Ready, Reconciling,
+// Stalled and Error conditions from the status of its components. Error and
+// Stalled are deliberately distinct: Error means a component explicitly
+// reported failure (StatusFailed); Stalled means a component's own
+// controller is stuck making progress (StatusStalled) without reporting an
+// outright failure — e.g. a Deployment past its progress deadline. Stalled
+// components are not retried by anyInProgress, since requeuing won't help a
+// component whose own controller has already said it's stuck.
+func aggregateConditions(status *appv1beta1.ApplicationStatus, objectStatuses []appv1beta1.ObjectStatus) {
+	var failed, stalled, inProgress []string
+	for _, os := range objectStatuses {
+		switch os.Status {
+		case StatusFailed:
+			failed = append(failed, os.Name)
+		case StatusStalled:
+			stalled = append(stalled, os.Name)
+		case StatusInProgress, StatusTerminating, StatusNotFound:
+			inProgress = append(inProgress, os.Name)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Ready, Status: metav1.ConditionFalse, Reason: "ComponentsFailed", Message: fmt.Sprintf("components failed: %v", failed)})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Error, Status: metav1.ConditionTrue, Reason: "ComponentsFailed", Message: fmt.Sprintf("components failed: %v", failed)})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Reconciling, Status: metav1.ConditionFalse, Reason: "ComponentsFailed", Message: "not reconciling while components are failed"})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Stalled, Status: metav1.ConditionFalse, Reason: "ComponentsFailed", Message: ""})
+	case len(stalled) > 0:
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Ready, Status: metav1.ConditionFalse, Reason: "ComponentsStalled", Message: fmt.Sprintf("components stalled: %v", stalled)})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Error, Status: metav1.ConditionFalse, Reason: "ComponentsStalled", Message: ""})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Reconciling, Status: metav1.ConditionFalse, Reason: "ComponentsStalled", Message: "not reconciling while components are stalled"})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Stalled, Status: metav1.ConditionTrue, Reason: "ComponentsStalled", Message: fmt.Sprintf("components stalled: %v", stalled)})
+	case len(inProgress) > 0:
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Ready, Status: metav1.ConditionFalse, Reason: "ComponentsNotReady", Message: fmt.Sprintf("components not ready: %v", inProgress)})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Error, Status: metav1.ConditionFalse, Reason: "ComponentsNotReady", Message: ""})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Reconciling, Status: metav1.ConditionTrue, Reason: "ComponentsNotReady", Message: fmt.Sprintf("components not ready: %v", inProgress)})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Stalled, Status: metav1.ConditionFalse, Reason: "ComponentsNotReady", Message: ""})
+	default:
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Ready, Status: metav1.ConditionTrue, Reason: "ComponentsReady", Message: "all components ready"})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Error, Status: metav1.ConditionFalse, Reason: "ComponentsReady", Message: ""})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Reconciling, Status: metav1.ConditionFalse, Reason: "ComponentsReady", Message: ""})
+		setCondition(status, appv1beta1.ApplicationCondition{Type: appv1beta1.Stalled, Status: metav1.ConditionFalse, Reason: "ComponentsReady", Message: ""})
+	}
+}
+
+// setCondition sets condition on status, updating LastTransitionTime only
+// when the status of the condition type actually changes.
+func setCondition(status *appv1beta1.ApplicationStatus, condition appv1beta1.ApplicationCondition) {
+	now := metav1.Now()
+	for i, existing := range status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = now
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	condition.LastTransitionTime = now
+	status.Conditions = append(status.Conditions, condition)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	appv1beta1 "sigs.k8s.io/application/api/v1beta1"
+)
+
+// templateFieldManager is the field manager used for every server-side
+// apply issued by the templating subsystem, so the controller's own writes
+// are distinguishable from, and don't fight with, other actors touching the
+// same object.
+const templateFieldManager = "application-controller"
+
+// templateSourceHTTPTimeout bounds how long fetchTemplateSource waits on a
+// URL template source, so a slow or hung host can't block a reconcile
+// worker indefinitely.
+const templateSourceHTTPTimeout = 10 * time.Second
+
+// templateSourceHTTPClient is used for every URL template source fetch.
+var templateSourceHTTPClient = &http.Client{Timeout: templateSourceHTTPTimeout}
+
+// reconcileTemplates renders app.Spec.Templates and applies each result
+// with server-side apply, correcting drift on every reconcile. Objects that
+// were rendered by a previous reconcile but no longer correspond to any
+// template (because it was removed or renamed) are deleted; the owner
+// reference the controller sets also lets Kubernetes garbage-collect them
+// if the Application itself is deleted.
+func (r *ApplicationReconciler) reconcileTemplates(ctx context.Context, app *appv1beta1.Application) ([]appv1beta1.TemplatedObjectRef, error) {
+	if len(app.Spec.Templates) == 0 && len(app.Status.TemplatedObjects) == 0 {
+		return nil, nil
+	}
+	logger := getLoggerOrDie(ctx)
+
+	ownerRef := metav1.NewControllerRef(app, appv1beta1.GroupVersion.WithKind("Application"))
+	*ownerRef.Controller = false
+
+	var applied []appv1beta1.TemplatedObjectRef
+	for _, tmpl := range app.Spec.Templates {
+		obj, err := r.renderTemplate(ctx, app, tmpl)
+		if err != nil {
+			return applied, fmt.Errorf("rendering template %q: %w", tmpl.Name, err)
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(app.Namespace)
+		}
+		obj.SetOwnerReferences([]metav1.OwnerReference{*ownerRef})
+
+		if err := r.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(templateFieldManager), client.ForceOwnership); err != nil {
+			return applied, fmt.Errorf("applying template %q: %w", tmpl.Name, err)
+		}
+		applied = append(applied, appv1beta1.TemplatedObjectRef{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+
+	for _, previous := range app.Status.TemplatedObjects {
+		if containsTemplatedObjectRef(applied, previous) {
+			continue
+		}
+		stale := &unstructured.Unstructured{}
+		stale.SetAPIVersion(previous.APIVersion)
+		stale.SetKind(previous.Kind)
+		stale.SetNamespace(previous.Namespace)
+		stale.SetName(previous.Name)
+		if err := r.Client.Delete(ctx, stale); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "ErrorDeletingStaleTemplatedObject", "gvk", previous.APIVersion+"/"+previous.Kind,
+				"namespace", previous.Namespace, "name", previous.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+func containsTemplatedObjectRef(refs []appv1beta1.TemplatedObjectRef, ref appv1beta1.TemplatedObjectRef) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTemplate resolves tmpl's manifest source, renders it as a
+// text/template with `.Application` bound to app, and decodes the result as
+// a single unstructured object.
+func (r *ApplicationReconciler) renderTemplate(ctx context.Context, app *appv1beta1.Application, tmpl appv1beta1.TemplateSource) (*unstructured.Unstructured, error) {
+	raw, err := r.fetchTemplateSource(ctx, app, tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(tmpl.Name).Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, map[string]interface{}{"Application": app}); err != nil {
+		return nil, fmt.Errorf("executing: %w", err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(buf.Bytes(), &obj.Object); err != nil {
+		return nil, fmt.Errorf("decoding rendered manifest: %w", err)
+	}
+	return obj, nil
+}
+
+// fetchTemplateSource returns the raw, unrendered template manifest for
+// tmpl, from whichever of Inline, ConfigMapRef or URL is set.
+func (r *ApplicationReconciler) fetchTemplateSource(ctx context.Context, app *appv1beta1.Application, tmpl appv1beta1.TemplateSource) (string, error) {
+	switch {
+	case tmpl.Inline != "":
+		return tmpl.Inline, nil
+	case tmpl.ConfigMapRef != nil:
+		var cm corev1.ConfigMap
+		key := client.ObjectKey{Namespace: app.Namespace, Name: tmpl.ConfigMapRef.Name}
+		if err := r.Client.Get(ctx, key, &cm); err != nil {
+			return "", fmt.Errorf("fetching ConfigMap %s: %w", tmpl.ConfigMapRef.Name, err)
+		}
+		data, ok := cm.Data[tmpl.ConfigMapRef.Key]
+		if !ok {
+			return "", fmt.Errorf("ConfigMap %s has no key %q", tmpl.ConfigMapRef.Name, tmpl.ConfigMapRef.Key)
+		}
+		return data, nil
+	case tmpl.URL != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tmpl.URL, nil)
+		if err != nil {
+			return "", fmt.Errorf("building request for %s: %w", tmpl.URL, err)
+		}
+		resp, err := templateSourceHTTPClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("fetching %s: %w", tmpl.URL, err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", tmpl.URL, err)
+		}
+		return string(body), nil
+	default:
+		return "", fmt.Errorf("template %q has no source (one of inline, configMapRef or url is required)", tmpl.Name)
+	}
+}